@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tao08141/double_udp/logging"
+)
+
+// adminapiComponent exposes Router's control-plane operations over HTTP.
+// Like httpadmin it listens on cfg.ListenAddr but never participates in
+// packet routing.
+type adminapiComponent struct {
+	cfg    ComponentConfig
+	router *Router
+	log    *logging.Logger
+
+	server *http.Server
+}
+
+// NewAdminAPIComponent creates an adminapi component.
+func NewAdminAPIComponent(cfg ComponentConfig, router *Router) (Component, error) {
+	return &adminapiComponent{
+		cfg:    cfg,
+		router: router,
+		log:    router.Logger().Named(cfg.Tag),
+	}, nil
+}
+
+func (a *adminapiComponent) GetTag() string {
+	return a.cfg.Tag
+}
+
+func (a *adminapiComponent) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/components", a.handleListComponents)
+	mux.HandleFunc("/stats", a.handleStats)
+	mux.HandleFunc("/drain", a.handleDrain)
+	mux.HandleFunc("/reload", a.handleReload)
+
+	a.server = &http.Server{Addr: a.cfg.ListenAddr, Handler: mux}
+
+	go func() {
+		if err := a.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			a.log.Error("adminapi server stopped", "component", a.cfg.Tag, "error", err)
+		}
+	}()
+	return nil
+}
+
+func (a *adminapiComponent) Stop() error {
+	return a.server.Shutdown(context.Background())
+}
+
+// HandlePacket is a no-op: adminapi never receives routed packets.
+func (a *adminapiComponent) HandlePacket(packet Packet) error {
+	return nil
+}
+
+func (a *adminapiComponent) handleListComponents(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, a.router.ListComponents())
+}
+
+func (a *adminapiComponent) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, a.router.GetStats())
+}
+
+func (a *adminapiComponent) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		http.Error(w, "tag query parameter is required", http.StatusBadRequest)
+		return
+	}
+	if err := a.router.DrainComponent(tag); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminapiComponent) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+	report, err := a.router.ReloadFromDisk()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, report)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,42 @@
+package main
+
+// ComponentConfig represents the common configuration for all components
+type ComponentConfig struct {
+	Type                string   `json:"type"`
+	Tag                 string   `json:"tag"`
+	ListenAddr          string   `json:"listen_addr"`
+	BufferSize          int      `json:"buffer_size"`
+	Timeout             int      `json:"timeout"`
+	ReplaceOldConns     bool     `json:"replace_old_conns"`
+	Forwarders          []string `json:"forwarders"`
+	QueueSize           int      `json:"queue_size"`
+	ReconnectInterval   int      `json:"reconnect_interval"`
+	ConnectionCheckTime int      `json:"connection_check_time"`
+	Detour              []string `json:"detour"`
+	// SocketPath, when set on an "external" component, is the Unix socket
+	// an out-of-process helper binary connects to speak the length-prefixed
+	// packet protocol implemented by externalComponent.
+	SocketPath string `json:"socket_path"`
+	// MaxFrameSize bounds the payload length an "external" component will
+	// accept from its length-prefixed frame header before allocating a
+	// buffer for it. Zero uses externalComponent's default.
+	MaxFrameSize int `json:"max_frame_size"`
+	// Route, when non-empty, replaces the plain Forwarders broadcast with
+	// one or more RouteRules evaluated by Router.Dispatch.
+	Route []RouteRule `json:"route"`
+}
+
+// Component is the interface that all network components must implement
+type Component interface {
+	Start() error
+	Stop() error
+	GetTag() string
+	// HandlePacket processes packets coming from other components
+	// srcTag is the tag of the component that sent the packet
+	HandlePacket(packet Packet) error
+}
+
+// ComponentFactory builds a Component from its configuration. Factories are
+// registered on a Router under a type name via RegisterComponentType and
+// invoked in place of the old hard-coded switch in main.go.
+type ComponentFactory func(cfg ComponentConfig, router *Router) (Component, error)
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/tao08141/double_udp/logging"
+)
+
+// defaultMaxFrameSize bounds an external component's frame payload when
+// cfg.MaxFrameSize is unset, so a misbehaving helper can't make readLoop
+// allocate an unbounded buffer from an attacker-controlled length prefix.
+const defaultMaxFrameSize = 65536
+
+// externalComponent bridges the router to an out-of-process helper binary
+// connected over a Unix socket (cfg.SocketPath). It speaks a minimal
+// length-prefixed protocol: each packet is a 4-byte big-endian length
+// followed by that many bytes of payload. This gives users a second way
+// (besides Go plugins) to add component types such as kcp-forward without
+// linking against this module at all.
+type externalComponent struct {
+	cfg          ComponentConfig
+	router       *Router
+	log          *logging.Logger
+	maxFrameSize uint32
+
+	mu   sync.RWMutex
+	conn net.Conn
+
+	stopOnce sync.Once
+	stopErr  error
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewExternalComponent connects to cfg.SocketPath. It matches the
+// ComponentFactory signature so it can be registered directly.
+func NewExternalComponent(cfg ComponentConfig, router *Router) (Component, error) {
+	if cfg.SocketPath == "" {
+		return nil, fmt.Errorf("external component %s: socket_path is required", cfg.Tag)
+	}
+	maxFrameSize := cfg.MaxFrameSize
+	if maxFrameSize <= 0 {
+		maxFrameSize = defaultMaxFrameSize
+	}
+	return &externalComponent{
+		cfg:          cfg,
+		router:       router,
+		log:          router.Logger().Named(cfg.Tag),
+		maxFrameSize: uint32(maxFrameSize),
+		stopCh:       make(chan struct{}),
+	}, nil
+}
+
+func (e *externalComponent) GetTag() string {
+	return e.cfg.Tag
+}
+
+func (e *externalComponent) Start() error {
+	conn, err := net.Dial("unix", e.cfg.SocketPath)
+	if err != nil {
+		return fmt.Errorf("external component %s: %w", e.cfg.Tag, err)
+	}
+	e.conn = conn
+
+	e.wg.Add(1)
+	go e.readLoop()
+	return nil
+}
+
+// Stop is idempotent: DrainComponent and Reload can both end up calling it
+// on the same component (e.g. a SIGHUP racing an adminapi /reload), and a
+// second close(e.stopCh) would otherwise panic.
+func (e *externalComponent) Stop() error {
+	e.stopOnce.Do(func() {
+		close(e.stopCh)
+		if e.conn != nil {
+			e.stopErr = e.conn.Close()
+		}
+		e.wg.Wait()
+	})
+	return e.stopErr
+}
+
+func (e *externalComponent) readLoop() {
+	defer e.wg.Done()
+
+	var lenBuf [4]byte
+	for {
+		if _, err := io.ReadFull(e.conn, lenBuf[:]); err != nil {
+			select {
+			case <-e.stopCh:
+				return
+			default:
+				e.log.Error("external socket closed", "component", e.cfg.Tag, "error", err)
+				return
+			}
+		}
+
+		n := binary.BigEndian.Uint32(lenBuf[:])
+		if n > e.maxFrameSize {
+			e.log.Error("external frame exceeds max_frame_size, closing", "component", e.cfg.Tag, "frame_size", n, "max_frame_size", e.maxFrameSize)
+			return
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(e.conn, data); err != nil {
+			e.log.Error("external frame read error", "component", e.cfg.Tag, "error", err)
+			return
+		}
+
+		e.log.Trace("forward", "received packet", "component", e.cfg.Tag, "src_tag", e.cfg.Tag, "bytes", n)
+		packet := e.router.NewPacket(e.cfg.Tag, data, nil)
+		if err := e.router.Dispatch(packet, e.cfg); err != nil {
+			e.log.Error("route error", "component", e.cfg.Tag, "error", err)
+		}
+	}
+}
+
+// HandlePacket frames a packet and writes it to the external helper.
+func (e *externalComponent) HandlePacket(packet Packet) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var lenBuf [4]byte
+	binary.BigEndian.PutUint32(lenBuf[:], uint32(len(packet.data)))
+	if _, err := e.conn.Write(lenBuf[:]); err != nil {
+		return err
+	}
+	_, err := e.conn.Write(packet.data)
+	return err
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tao08141/double_udp/logging"
+)
+
+// ForwardComponent dials out to a remote UDP endpoint (cfg.ListenAddr) and
+// relays packets between that connection and the rest of the pipeline.
+// Packets handed to it via HandlePacket are queued (bounded by
+// cfg.QueueSize) and written to the outbound connection; packets read off
+// the connection are routed to cfg.Forwarders, falling back to cfg.Detour
+// only when Forwarders is empty (a failed delivery to an existing forwarder
+// is not retried against Detour).
+type ForwardComponent struct {
+	cfg    ComponentConfig
+	router *Router
+	log    *logging.Logger
+
+	// cfgMu guards the subset of cfg that Reconfigure can change live
+	// (Forwarders, Detour, ReconnectInterval); everything else is set once
+	// at construction and read without locking.
+	cfgMu sync.RWMutex
+
+	mu   sync.RWMutex
+	conn net.Conn
+
+	outbox chan []byte
+
+	stopOnce sync.Once
+	stopErr  error
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewForwardComponent creates a forward-side component.
+func NewForwardComponent(cfg ComponentConfig, router *Router) *ForwardComponent {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 64
+	}
+	return &ForwardComponent{
+		cfg:    cfg,
+		router: router,
+		log:    router.Logger().Named(cfg.Tag),
+		outbox: make(chan []byte, queueSize),
+		stopCh: make(chan struct{}),
+	}
+}
+
+func (f *ForwardComponent) GetTag() string {
+	return f.cfg.Tag
+}
+
+func (f *ForwardComponent) Start() error {
+	if err := f.dial(); err != nil {
+		return err
+	}
+
+	f.wg.Add(2)
+	go f.readLoop()
+	go f.writeLoop()
+	if f.cfg.ConnectionCheckTime > 0 {
+		f.wg.Add(1)
+		go f.healthLoop()
+	}
+	return nil
+}
+
+// Stop is idempotent: DrainComponent and Reload can both end up calling it
+// on the same component (e.g. a SIGHUP racing an adminapi /reload), and a
+// second close(f.stopCh) would otherwise panic.
+func (f *ForwardComponent) Stop() error {
+	f.stopOnce.Do(func() {
+		close(f.stopCh)
+		f.mu.RLock()
+		conn := f.conn
+		f.mu.RUnlock()
+		if conn != nil {
+			f.stopErr = conn.Close()
+		}
+		f.wg.Wait()
+	})
+	return f.stopErr
+}
+
+func (f *ForwardComponent) dial() error {
+	conn, err := net.Dial("udp", f.cfg.ListenAddr)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.conn = conn
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *ForwardComponent) reconnect() {
+	f.cfgMu.RLock()
+	interval := time.Duration(f.cfg.ReconnectInterval) * time.Second
+	f.cfgMu.RUnlock()
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		select {
+		case <-f.stopCh:
+			return
+		case <-time.After(interval):
+		}
+		if err := f.dial(); err != nil {
+			f.log.Error("reconnect failed", "component", f.cfg.Tag, "error", err)
+			continue
+		}
+		return
+	}
+}
+
+func (f *ForwardComponent) readLoop() {
+	defer f.wg.Done()
+
+	for {
+		select {
+		case <-f.stopCh:
+			return
+		default:
+		}
+
+		f.mu.RLock()
+		conn := f.conn
+		f.mu.RUnlock()
+
+		buf := f.router.GetBuffer()
+		n, err := conn.Read(buf)
+		if err != nil {
+			f.router.PutBuffer(buf)
+			select {
+			case <-f.stopCh:
+				return
+			default:
+				f.log.Error("read error", "component", f.cfg.Tag, "error", err)
+				f.reconnect()
+				continue
+			}
+		}
+
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		f.router.PutBuffer(buf)
+
+		f.log.Trace("forward", "received packet", "component", f.cfg.Tag, "src_tag", f.cfg.Tag, "bytes", n)
+		f.routeUpstream(f.router.NewPacket(f.cfg.Tag, data, nil))
+	}
+}
+
+// routeUpstream sends a packet per cfg.Route when set, otherwise to
+// cfg.Forwarders, falling back to cfg.Detour when no forwarder is
+// registered under that tag.
+func (f *ForwardComponent) routeUpstream(packet Packet) {
+	f.cfgMu.RLock()
+	routeCfg := f.cfg
+	f.cfgMu.RUnlock()
+
+	if len(routeCfg.Route) != 0 {
+		if err := f.router.Dispatch(packet, routeCfg); err != nil {
+			f.log.Error("route error", "component", f.cfg.Tag, "error", err)
+		}
+		return
+	}
+
+	targets := routeCfg.Forwarders
+	if len(targets) == 0 {
+		targets = routeCfg.Detour
+	}
+	if err := f.router.Route(packet, targets); err != nil {
+		f.log.Error("route error", "component", f.cfg.Tag, "error", err)
+	}
+}
+
+// Reconfigure applies a new Forwarders/Detour/ReconnectInterval live. The
+// QueueSize field is also accepted for Router.Reload's diffing purposes but
+// has no effect until the component is restarted, since an existing Go
+// channel cannot be resized.
+func (f *ForwardComponent) Reconfigure(cfg ComponentConfig) {
+	f.cfgMu.Lock()
+	f.cfg.Forwarders = cfg.Forwarders
+	f.cfg.Detour = cfg.Detour
+	f.cfg.ReconnectInterval = cfg.ReconnectInterval
+	f.cfg.QueueSize = cfg.QueueSize
+	f.cfgMu.Unlock()
+}
+
+func (f *ForwardComponent) writeLoop() {
+	defer f.wg.Done()
+
+	for {
+		select {
+		case <-f.stopCh:
+			return
+		case data := <-f.outbox:
+			f.router.SetQueueDepth(f.cfg.Tag, len(f.outbox))
+
+			f.mu.RLock()
+			conn := f.conn
+			f.mu.RUnlock()
+
+			if f.cfg.Timeout > 0 {
+				conn.SetWriteDeadline(time.Now().Add(time.Duration(f.cfg.Timeout) * time.Second))
+			}
+			f.log.Trace("forward", "sending packet upstream", "component", f.cfg.Tag, "dst_tag", f.cfg.Tag, "bytes", len(data))
+			if _, err := conn.Write(data); err != nil {
+				f.log.Error("write error", "component", f.cfg.Tag, "error", err)
+			}
+		}
+	}
+}
+
+// healthLoop periodically probes the outbound connection, redialing it if
+// it has gone stale.
+func (f *ForwardComponent) healthLoop() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(f.cfg.ConnectionCheckTime) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stopCh:
+			return
+		case <-ticker.C:
+			f.mu.RLock()
+			conn := f.conn
+			f.mu.RUnlock()
+			if _, err := conn.Write([]byte{}); err != nil {
+				f.log.Warn("health check failed, reconnecting", "component", f.cfg.Tag, "error", err)
+				f.reconnect()
+			}
+		}
+	}
+}
+
+// HandlePacket queues a packet to be written to the outbound connection.
+func (f *ForwardComponent) HandlePacket(packet Packet) error {
+	select {
+	case f.outbox <- packet.data:
+		f.router.SetQueueDepth(f.cfg.Tag, len(f.outbox))
+		return nil
+	default:
+		f.log.Warn("outbox full, dropping packet", "component", f.cfg.Tag, "bytes", len(packet.data))
+		return nil
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tao08141/double_udp/logging"
+)
+
+// httpadminComponent exposes the router's Prometheus metrics over HTTP. It
+// is a Component like any other (registered via cfg.Type == "httpadmin")
+// but never participates in packet routing: it listens on cfg.ListenAddr
+// and serves GET /metrics.
+type httpadminComponent struct {
+	cfg    ComponentConfig
+	router *Router
+	log    *logging.Logger
+
+	server *http.Server
+}
+
+// NewHTTPAdminComponent creates an httpadmin component. Its tag is
+// configurable like any other component, so multiple pipelines in one
+// process can each expose their own /metrics if needed. A component that
+// doesn't set its own listen_addr falls back to Observability.PrometheusAddr.
+func NewHTTPAdminComponent(cfg ComponentConfig, router *Router) (Component, error) {
+	if cfg.ListenAddr == "" {
+		cfg.ListenAddr = router.DefaultPrometheusAddr()
+	}
+	return &httpadminComponent{
+		cfg:    cfg,
+		router: router,
+		log:    router.Logger().Named(cfg.Tag),
+	}, nil
+}
+
+func (h *httpadminComponent) GetTag() string {
+	return h.cfg.Tag
+}
+
+func (h *httpadminComponent) Start() error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(h.router.MetricsRegistry(), promhttp.HandlerOpts{}))
+
+	h.server = &http.Server{Addr: h.cfg.ListenAddr, Handler: mux}
+
+	go func() {
+		if err := h.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			h.log.Error("httpadmin server stopped", "component", h.cfg.Tag, "error", err)
+		}
+	}()
+	return nil
+}
+
+func (h *httpadminComponent) Stop() error {
+	return h.server.Shutdown(context.Background())
+}
+
+// HandlePacket is a no-op: httpadmin never receives routed packets.
+func (h *httpadminComponent) HandlePacket(packet Packet) error {
+	return nil
+}
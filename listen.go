@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tao08141/double_udp/logging"
+)
+
+// ListenComponent owns a UDP server socket. Every packet it reads is routed
+// to cfg.Forwarders; packets routed back to it (HandlePacket) are written to
+// whichever client last sent it data, unless ReplaceOldConns is false, in
+// which case the first client address wins and later clients are ignored.
+type ListenComponent struct {
+	cfg    ComponentConfig
+	router *Router
+	log    *logging.Logger
+
+	// cfgMu guards the subset of cfg that Reconfigure can change live
+	// (Forwarders, Detour).
+	cfgMu sync.RWMutex
+
+	conn net.PacketConn
+
+	mu         sync.RWMutex
+	clientAddr net.Addr
+
+	stopOnce sync.Once
+	stopErr  error
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewListenComponent creates a listen-side component bound to cfg.ListenAddr.
+func NewListenComponent(cfg ComponentConfig, router *Router) *ListenComponent {
+	return &ListenComponent{
+		cfg:    cfg,
+		router: router,
+		log:    router.Logger().Named(cfg.Tag),
+		stopCh: make(chan struct{}),
+	}
+}
+
+func (l *ListenComponent) GetTag() string {
+	return l.cfg.Tag
+}
+
+func (l *ListenComponent) Start() error {
+	conn, err := net.ListenPacket("udp", l.cfg.ListenAddr)
+	if err != nil {
+		return err
+	}
+	l.conn = conn
+
+	l.wg.Add(1)
+	go l.readLoop()
+	return nil
+}
+
+// Stop is idempotent: DrainComponent and Reload can both end up calling it
+// on the same component (e.g. a SIGHUP racing an adminapi /reload), and a
+// second close(l.stopCh) would otherwise panic.
+func (l *ListenComponent) Stop() error {
+	l.stopOnce.Do(func() {
+		close(l.stopCh)
+		if l.conn != nil {
+			l.stopErr = l.conn.Close()
+		}
+		l.wg.Wait()
+	})
+	return l.stopErr
+}
+
+func (l *ListenComponent) readLoop() {
+	defer l.wg.Done()
+
+	for {
+		buf := l.router.GetBuffer()
+		n, addr, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			l.router.PutBuffer(buf)
+			select {
+			case <-l.stopCh:
+				return
+			default:
+				l.log.Error("read error", "component", l.cfg.Tag, "error", err)
+				return
+			}
+		}
+
+		l.rememberClient(addr)
+
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		l.router.PutBuffer(buf)
+
+		l.log.Trace("forward", "received packet", "component", l.cfg.Tag, "src_tag", l.cfg.Tag, "bytes", n)
+		packet := l.router.NewPacket(l.cfg.Tag, data, addr)
+		l.cfgMu.RLock()
+		routeCfg := l.cfg
+		l.cfgMu.RUnlock()
+		if err := l.router.Dispatch(packet, routeCfg); err != nil {
+			l.log.Error("route error", "component", l.cfg.Tag, "error", err)
+		}
+	}
+}
+
+// Reconfigure applies a new Forwarders/Detour list live.
+func (l *ListenComponent) Reconfigure(cfg ComponentConfig) {
+	l.cfgMu.Lock()
+	l.cfg.Forwarders = cfg.Forwarders
+	l.cfg.Detour = cfg.Detour
+	l.cfgMu.Unlock()
+}
+
+// rememberClient records addr as the destination for replies. When
+// ReplaceOldConns is false the first client to connect keeps that slot for
+// the lifetime of the component.
+func (l *ListenComponent) rememberClient(addr net.Addr) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.clientAddr != nil && !l.cfg.ReplaceOldConns {
+		return
+	}
+	l.clientAddr = addr
+	l.router.SetConnectionCount(l.cfg.Tag, 1)
+}
+
+// HandlePacket writes a packet coming back from a forwarder out to the
+// client currently associated with this listener.
+func (l *ListenComponent) HandlePacket(packet Packet) error {
+	l.mu.RLock()
+	addr := l.clientAddr
+	l.mu.RUnlock()
+
+	if addr == nil {
+		return nil // no client has connected yet, drop
+	}
+
+	if l.cfg.Timeout > 0 {
+		l.conn.SetWriteDeadline(time.Now().Add(time.Duration(l.cfg.Timeout) * time.Second))
+	}
+	l.log.Trace("forward", "sending packet to client", "component", l.cfg.Tag, "src_tag", packet.srcTag, "dst_tag", l.cfg.Tag, "bytes", len(packet.data))
+	_, err := l.conn.WriteTo(packet.data, addr)
+	return err
+}
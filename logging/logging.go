@@ -0,0 +1,255 @@
+// Package logging provides the leveled, per-component loggers used
+// throughout this module in place of bare calls to the standard log
+// package.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is a logging severity. Lower values are more verbose.
+type Level int32
+
+const (
+	LevelTrace Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	// LevelOff disables all logging.
+	LevelOff
+)
+
+// ParseLevel parses a level name, defaulting to LevelInfo for unknown input.
+func ParseLevel(s string) Level {
+	switch strings.ToLower(s) {
+	case "trace":
+		return LevelTrace
+	case "debug":
+		return LevelDebug
+	case "info", "":
+		return LevelInfo
+	case "warn", "warning":
+		return LevelWarn
+	case "error":
+		return LevelError
+	case "off":
+		return LevelOff
+	default:
+		return LevelInfo
+	}
+}
+
+func (l Level) String() string {
+	switch l {
+	case LevelTrace:
+		return "trace"
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "off"
+	}
+}
+
+// Format selects how log lines are rendered.
+type Format int
+
+const (
+	FormatText Format = iota
+	FormatJSON
+)
+
+// ParseFormat parses a Config.LogFormat value, defaulting to FormatText.
+func ParseFormat(s string) Format {
+	if strings.EqualFold(s, "json") {
+		return FormatJSON
+	}
+	return FormatText
+}
+
+// shared is the state a family of named loggers (root plus every logger
+// derived from it via Named) has in common, so changing the level or trace
+// categories on one affects all of them.
+type shared struct {
+	mu        sync.Mutex
+	out       io.Writer
+	format    Format
+	level     Level
+	traceCats map[string]bool // nil/empty means no trace category is enabled
+}
+
+// Logger is a leveled, named logger. The zero value is not usable; create
+// one with New or NewFromEnv.
+type Logger struct {
+	name   string
+	fields []field
+	s      *shared
+}
+
+type field struct {
+	key string
+	val any
+}
+
+// New creates a root logger writing to out in the given format at level.
+func New(format Format, level Level, out io.Writer) *Logger {
+	return &Logger{s: &shared{out: out, format: format, level: level}}
+}
+
+// NewFromEnv creates a root logger whose trace categories are taken from
+// the DUDP_TRACE environment variable, a comma-separated list such as
+// "route,pool,forward". An empty/unset DUDP_TRACE disables Trace logging
+// entirely regardless of level.
+func NewFromEnv(format Format, level Level, out io.Writer) *Logger {
+	l := New(format, level, out)
+	if v := os.Getenv("DUDP_TRACE"); v != "" {
+		cats := make(map[string]bool)
+		for _, c := range strings.Split(v, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				cats[c] = true
+			}
+		}
+		l.s.traceCats = cats
+	}
+	return l
+}
+
+// Named returns a child logger whose name is "parent.name" (or just name if
+// the parent is unnamed). It shares level, format and output with its
+// parent, so adjusting the root logger's level affects every descendant.
+func (l *Logger) Named(name string) *Logger {
+	child := &Logger{s: l.s, fields: l.fields}
+	if l.name == "" {
+		child.name = name
+	} else {
+		child.name = l.name + "." + name
+	}
+	return child
+}
+
+// With returns a child logger that always includes the given key/value
+// pairs in every subsequent log line.
+func (l *Logger) With(kvs ...any) *Logger {
+	child := &Logger{name: l.name, s: l.s, fields: append(append([]field{}, l.fields...), kvPairs(kvs)...)}
+	return child
+}
+
+func kvPairs(kvs []any) []field {
+	fields := make([]field, 0, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		key, _ := kvs[i].(string)
+		fields = append(fields, field{key: key, val: kvs[i+1]})
+	}
+	return fields
+}
+
+// traceEnabled reports whether category is one of the DUDP_TRACE categories
+// for this logger family. It is cheap enough to call on every Trace
+// invocation: a nil map lookup, no allocation.
+func (l *Logger) traceEnabled(category string) bool {
+	return l.s.traceCats[category]
+}
+
+// Trace logs msg under category if both the logger's level allows Trace and
+// category is enabled via DUDP_TRACE. Both checks happen before any
+// formatting or allocation, so a disabled Trace call costs a couple of
+// comparisons on the hot path.
+func (l *Logger) Trace(category, msg string, kvs ...any) {
+	if l.s.level > LevelTrace || !l.traceEnabled(category) {
+		return
+	}
+	l.log(LevelTrace, msg, kvs)
+}
+
+func (l *Logger) Debug(msg string, kvs ...any) {
+	if l.s.level > LevelDebug {
+		return
+	}
+	l.log(LevelDebug, msg, kvs)
+}
+
+func (l *Logger) Info(msg string, kvs ...any) {
+	if l.s.level > LevelInfo {
+		return
+	}
+	l.log(LevelInfo, msg, kvs)
+}
+
+func (l *Logger) Warn(msg string, kvs ...any) {
+	if l.s.level > LevelWarn {
+		return
+	}
+	l.log(LevelWarn, msg, kvs)
+}
+
+func (l *Logger) Error(msg string, kvs ...any) {
+	if l.s.level > LevelError {
+		return
+	}
+	l.log(LevelError, msg, kvs)
+}
+
+func (l *Logger) log(level Level, msg string, kvs []any) {
+	fields := l.fields
+	if len(kvs) > 0 {
+		fields = append(append([]field{}, fields...), kvPairs(kvs)...)
+	}
+
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+
+	if l.s.format == FormatJSON {
+		l.writeJSON(level, msg, fields)
+		return
+	}
+	l.writeText(level, msg, fields)
+}
+
+func (l *Logger) writeText(level Level, msg string, fields []field) {
+	var b strings.Builder
+	b.WriteString(time.Now().Format("2006-01-02T15:04:05.000Z07:00"))
+	b.WriteByte(' ')
+	b.WriteString(level.String())
+	if l.name != "" {
+		b.WriteString(" [")
+		b.WriteString(l.name)
+		b.WriteString("]")
+	}
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for _, f := range fields {
+		fmt.Fprintf(&b, " %s=%v", f.key, f.val)
+	}
+	b.WriteByte('\n')
+	io.WriteString(l.s.out, b.String())
+}
+
+func (l *Logger) writeJSON(level Level, msg string, fields []field) {
+	entry := make(map[string]any, len(fields)+3)
+	entry["time"] = time.Now().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = msg
+	if l.name != "" {
+		entry["logger"] = l.name
+	}
+	for _, f := range fields {
+		entry[f.key] = f.val
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	l.s.out.Write(append(data, '\n'))
+}
@@ -1,146 +1,30 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
-	"fmt"
 	"log"
 	"os"
-	"sync"
+	"os/signal"
+	"syscall"
+
+	"github.com/tao08141/double_udp/logging"
 )
 
 // Config represents the top-level configuration structure
 type Config struct {
 	BufferSize int               `json:"buffer_size"`
 	Services   []ComponentConfig `json:"services"`
-}
-
-// ComponentConfig represents the common configuration for all components
-type ComponentConfig struct {
-	Type                string   `json:"type"`
-	Tag                 string   `json:"tag"`
-	ListenAddr          string   `json:"listen_addr"`
-	BufferSize          int      `json:"buffer_size"`
-	Timeout             int      `json:"timeout"`
-	ReplaceOldConns     bool     `json:"replace_old_conns"`
-	Forwarders          []string `json:"forwarders"`
-	QueueSize           int      `json:"queue_size"`
-	ReconnectInterval   int      `json:"reconnect_interval"`
-	ConnectionCheckTime int      `json:"connection_check_time"`
-	Detour              []string `json:"detour"`
-}
-
-// Component is the interface that all network components must implement
-type Component interface {
-	Start() error
-	Stop() error
-	GetTag() string
-	// HandlePacket processes packets coming from other components
-	// srcTag is the tag of the component that sent the packet
-	HandlePacket(packet Packet) error
-}
-
-// Router manages all components and routes packets between them
-type Router struct {
-	components map[string]Component
-	mu         sync.RWMutex
-	bufferPool sync.Pool
-}
-
-// NewRouter creates a new router
-func NewRouter(config Config) *Router {
-	return &Router{
-		components: make(map[string]Component),
-		bufferPool: sync.Pool{
-			New: func() any {
-				buf := make([]byte, config.BufferSize)
-				return &buf // Return pointer to slice
-			},
-		},
-	}
-}
-
-// GetBuffer retrieves a buffer from the pool
-func (r *Router) GetBuffer() []byte {
-	return *(r.bufferPool.Get().(*[]byte))
-}
-
-// PutBuffer returns a buffer to the pool
-func (r *Router) PutBuffer(buf []byte) {
-	r.bufferPool.Put(&buf)
-}
-
-// Register adds a component to the router
-func (r *Router) Register(c Component) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	tag := c.GetTag()
-	if tag == "" {
-		return fmt.Errorf("component has empty tag")
-	}
-
-	if _, exists := r.components[tag]; exists {
-		return fmt.Errorf("component with tag %s already registered", tag)
-	}
-
-	r.components[tag] = c
-	return nil
-}
-
-// GetComponent returns a component by its tag
-func (r *Router) GetComponent(tag string) (Component, bool) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	c, exists := r.components[tag]
-	return c, exists
-}
-
-// Route sends a packet to components specified by their tags
-func (r *Router) Route(packet Packet, destTags []string) error {
-	for _, tag := range destTags {
-		if tag == packet.srcTag {
-			continue // Don't route back to source
-		}
-
-		c, exists := r.GetComponent(tag)
-		if !exists {
-			log.Printf("Warning: trying to route to non-existing component: %s", tag)
-			continue
-		}
-
-		if err := c.HandlePacket(packet); err != nil {
-			log.Printf("Error routing to %s: %v", tag, err)
-		}
-	}
-	return nil
-}
-
-// StartAll starts all registered components
-func (r *Router) StartAll() error {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	for tag, component := range r.components {
-		log.Printf("Starting component: %s", tag)
-		if err := component.Start(); err != nil {
-			return fmt.Errorf("failed to start component %s: %w", tag, err)
-		}
-	}
-	return nil
-}
-
-// StopAll stops all registered components
-func (r *Router) StopAll() {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	for tag, component := range r.components {
-		log.Printf("Stopping component: %s", tag)
-		if err := component.Stop(); err != nil {
-			log.Printf("Error stopping component %s: %v", tag, err)
-		}
-	}
+	// Plugins lists Go plugin (.so) files to load at startup. Each one
+	// registers an additional component type on the router; see LoadPlugins.
+	Plugins []string `json:"plugins"`
+	// LogFormat is "text" (default) or "json".
+	LogFormat string `json:"log_format"`
+	// LogLevel is one of trace/debug/info/warn/error/off, default info.
+	LogLevel string `json:"log_level"`
+	// Observability configures OTLP tracing and Prometheus metrics.
+	Observability ObservabilityConfig `json:"observability"`
 }
 
 func main() {
@@ -160,8 +44,24 @@ func main() {
 		log.Fatalf("Failed to parse config: %v", err)
 	}
 
+	logger := logging.NewFromEnv(logging.ParseFormat(config.LogFormat), logging.ParseLevel(config.LogLevel), os.Stderr)
+
+	tracer, shutdownTracer, err := newTracer(context.Background(), config.Observability)
+	if err != nil {
+		logger.Error("failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+	defer shutdownTracer(context.Background())
+
 	// Initialize router with buffer pool
-	router := NewRouter(config)
+	router := NewRouter(config, logger.Named("router"), tracer)
+	router.RegisterBuiltin()
+	router.SetConfigPath(*configPath)
+
+	if err := LoadPlugins(config.Plugins, router); err != nil {
+		logger.Error("failed to load plugins", "error", err)
+		os.Exit(1)
+	}
 
 	// Create components based on config
 	for _, cfg := range config.Services {
@@ -170,28 +70,38 @@ func main() {
 			cfg.BufferSize = config.BufferSize
 		}
 
-		var component Component
-
-		switch cfg.Type {
-		case "listen":
-			component = NewListenComponent(cfg, router)
-		case "forward":
-			component = NewForwardComponent(cfg, router)
-		default:
-			log.Printf("Unknown component type: %s", cfg.Type)
+		component, err := router.NewComponent(cfg)
+		if err != nil {
+			logger.Error("failed to create component", "component", cfg.Tag, "error", err)
 			continue
 		}
 
-		if err := router.Register(component); err != nil {
-			log.Printf("Failed to register component %s: %v", cfg.Tag, err)
+		if err := router.RegisterWithConfig(cfg, component); err != nil {
+			logger.Error("failed to register component", "component", cfg.Tag, "error", err)
 		}
 	}
 
 	// Start all components
 	if err := router.StartAll(); err != nil {
-		log.Fatalf("Failed to start components: %v", err)
+		logger.Error("failed to start components", "error", err)
+		os.Exit(1)
 	}
 
+	// Reload config.json on SIGHUP without dropping in-flight packets; see
+	// Router.Reload.
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			report, err := router.ReloadFromDisk()
+			if err != nil {
+				logger.Error("reload failed", "error", err)
+				continue
+			}
+			logger.Info("reload complete", "results", report.Results)
+		}
+	}()
+
 	// Wait indefinitely
 	select {}
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ObservabilityConfig configures tracing and metrics for a Router. It is
+// optional: a zero value disables tracing (Router falls back to a no-op
+// tracer) while metrics are always registered, just never scraped unless an
+// httpadmin component exposes them.
+type ObservabilityConfig struct {
+	// OTLPEndpoint is the OTLP/gRPC collector address, e.g. "localhost:4317".
+	// Empty disables tracing.
+	OTLPEndpoint string `json:"otlp_endpoint"`
+	// SamplerRatio is the fraction of traces to sample, 0 < ratio <= 1.
+	// Defaults to 1 (always sample) when OTLPEndpoint is set and this is 0.
+	SamplerRatio float64 `json:"sampler_ratio"`
+	// PrometheusAddr, if set, is used as the default listen_addr for an
+	// httpadmin component that does not specify its own.
+	PrometheusAddr string `json:"prometheus_addr"`
+}
+
+// metrics holds the Prometheus collectors shared by the Router and every
+// component. All of them are labeled by "component" (a component's tag) so
+// a single registry can answer for the whole pipeline.
+type metrics struct {
+	registry *prometheus.Registry
+
+	packetsIn      *prometheus.CounterVec
+	packetsOut     *prometheus.CounterVec
+	bytesIn        *prometheus.CounterVec
+	bytesOut       *prometheus.CounterVec
+	routeErrors    *prometheus.CounterVec
+	bufferGets     prometheus.Counter
+	queueDepth     *prometheus.GaugeVec
+	connCount      *prometheus.GaugeVec
+	forwardLatency *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	registry := prometheus.NewRegistry()
+
+	m := &metrics{
+		registry: registry,
+		packetsIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "packets_in_total",
+			Help: "Packets received by a component.",
+		}, []string{"component"}),
+		packetsOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "packets_out_total",
+			Help: "Packets delivered to a component via Router.Route.",
+		}, []string{"component"}),
+		bytesIn: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bytes_in_total",
+			Help: "Bytes received by a component.",
+		}, []string{"component"}),
+		bytesOut: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bytes_out_total",
+			Help: "Bytes delivered to a component via Router.Route.",
+		}, []string{"component"}),
+		routeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "route_errors_total",
+			Help: "Errors returned by HandlePacket, by destination component.",
+		}, []string{"component"}),
+		bufferGets: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "buffer_pool_gets_total",
+			Help: "Calls to Router.GetBuffer.",
+		}),
+		queueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "queue_depth",
+			Help: "Current depth of a component's outbound queue.",
+		}, []string{"component"}),
+		connCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "connection_count",
+			Help: "Current number of live connections/clients held by a component.",
+		}, []string{"component"}),
+		forwardLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "forward_latency_seconds",
+			Help:    "End-to-end latency from a packet entering the router to being handed to its destination.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"component"}),
+	}
+
+	registry.MustRegister(
+		m.packetsIn, m.packetsOut, m.bytesIn, m.bytesOut,
+		m.routeErrors, m.bufferGets, m.queueDepth, m.connCount, m.forwardLatency,
+	)
+	return m
+}
+
+// SetQueueDepth lets a component (e.g. ForwardComponent's outbox) report its
+// current queue depth for the queue_depth gauge.
+func (r *Router) SetQueueDepth(tag string, depth int) {
+	r.metrics.queueDepth.WithLabelValues(tag).Set(float64(depth))
+}
+
+// SetConnectionCount lets a component report how many connections/clients it
+// currently holds for the connection_count gauge.
+func (r *Router) SetConnectionCount(tag string, n int) {
+	r.metrics.connCount.WithLabelValues(tag).Set(float64(n))
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"net"
+)
+
+// Packet is the unit of data passed between components through the Router.
+// Components that own a physical connection (listeners, forwarders) stamp
+// srcTag with their own tag before handing a Packet to the Router.
+//
+// ctx carries the tracing span for this packet's journey through the
+// pipeline. A component that reads a packet off the wire should start a new
+// span (see Router.Route) so that the listen->router->forward hops show up
+// as linked spans in a trace.
+type Packet struct {
+	srcTag string
+	data   []byte
+	addr   net.Addr
+	ctx    context.Context
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// LoadPlugins opens every .so listed in paths and registers the component
+// type each one exports. A plugin must export two symbols:
+//
+//	var ComponentType string
+//	var Factory func(ComponentConfig, *Router) (Component, error)
+//
+// This lets a user ship a new component (tls-listen, quic-forward, ...) as a
+// standalone Go plugin without forking this module, as long as it is built
+// against the same Go toolchain and Component/ComponentConfig definitions.
+func LoadPlugins(paths []string, router *Router) error {
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open plugin %s: %w", path, err)
+		}
+
+		nameSym, err := p.Lookup("ComponentType")
+		if err != nil {
+			return fmt.Errorf("plugin %s missing ComponentType: %w", path, err)
+		}
+		name, ok := nameSym.(*string)
+		if !ok {
+			return fmt.Errorf("plugin %s: ComponentType is not a string", path)
+		}
+
+		factorySym, err := p.Lookup("Factory")
+		if err != nil {
+			return fmt.Errorf("plugin %s missing Factory: %w", path, err)
+		}
+		factory, ok := factorySym.(*func(ComponentConfig, *Router) (Component, error))
+		if !ok {
+			return fmt.Errorf("plugin %s: Factory has unexpected signature", path)
+		}
+
+		router.Logger().Info("loaded plugin component type", "type", *name, "path", path)
+		router.RegisterComponentType(*name, *factory)
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WeightedTarget is one candidate destination within a RouteRule.
+type WeightedTarget struct {
+	Tag    string `json:"tag"`
+	Weight int    `json:"weight"`
+}
+
+// HealthCheckConfig configures the sliding-window outlier detection used to
+// eject an unhealthy target from a RouteRule's candidate set.
+type HealthCheckConfig struct {
+	// WindowSize is how many recent HandlePacket results to keep per
+	// target. Defaults to 20.
+	WindowSize int `json:"window_size"`
+	// ErrorThreshold is the fraction (0-1) of failures in the window that
+	// ejects a target. Zero disables ejection entirely.
+	ErrorThreshold float64 `json:"error_threshold"`
+	// CooldownSeconds is how long an ejected target is skipped before it
+	// is given another chance with a clean window.
+	CooldownSeconds int `json:"cooldown_seconds"`
+}
+
+// RouteRule describes how to pick destinations for packets matching it.
+type RouteRule struct {
+	// Match restricts this rule to packets from a given srcTag; empty
+	// matches every packet.
+	Match string `json:"match"`
+	// Strategy is a name registered on the Router via RegisterPolicy;
+	// RegisterBuiltinPolicies provides broadcast/round_robin/
+	// weighted_random/hash/failover.
+	Strategy    string            `json:"strategy"`
+	Targets     []WeightedTarget  `json:"targets"`
+	HealthCheck HealthCheckConfig `json:"health_check"`
+}
+
+// Policy picks which of a RouteRule's targets a packet should go to.
+// isHealthy reports whether a target tag is currently eligible; policies
+// that ignore health (broadcast) may call it or not as appropriate.
+type Policy interface {
+	SelectTargets(packet Packet, rule RouteRule, isHealthy func(tag string) bool) []string
+}
+
+// RegisterPolicy makes a routing strategy available under name for
+// ComponentConfig.Route rules. Re-registering a name overwrites it.
+func (r *Router) RegisterPolicy(name string, policy Policy) {
+	r.policiesMu.Lock()
+	defer r.policiesMu.Unlock()
+	r.policies[name] = policy
+}
+
+// RegisterBuiltinPolicies pre-registers this module's routing strategies.
+func (r *Router) RegisterBuiltinPolicies() {
+	r.RegisterPolicy("broadcast", broadcastPolicy{})
+	r.RegisterPolicy("round_robin", newRoundRobinPolicy())
+	r.RegisterPolicy("weighted_random", weightedRandomPolicy{})
+	r.RegisterPolicy("hash", hashPolicy{})
+	r.RegisterPolicy("failover", failoverPolicy{})
+}
+
+func (r *Router) policy(name string) (Policy, bool) {
+	r.policiesMu.RLock()
+	defer r.policiesMu.RUnlock()
+	p, ok := r.policies[name]
+	return p, ok
+}
+
+// Dispatch sends packet to the destinations described by cfg: if cfg.Route
+// is set, each matching rule's Policy picks the targets (and Route records
+// the resulting health signal for that rule's targets); otherwise it falls
+// back to the legacy cfg.Forwarders broadcast. The packet is counted once
+// against packetsIn/bytesIn no matter how many rules or targets it fans out
+// to; routeWithHealth only handles per-target delivery and health
+// bookkeeping.
+func (r *Router) Dispatch(packet Packet, cfg ComponentConfig) error {
+	if len(cfg.Route) == 0 {
+		return r.Route(packet, cfg.Forwarders)
+	}
+
+	r.metrics.packetsIn.WithLabelValues(packet.srcTag).Inc()
+	r.metrics.bytesIn.WithLabelValues(packet.srcTag).Add(float64(len(packet.data)))
+
+	var firstErr error
+	for _, rule := range cfg.Route {
+		if rule.Match != "" && rule.Match != packet.srcTag {
+			continue
+		}
+
+		policy, ok := r.policy(rule.Strategy)
+		if !ok {
+			r.logger.Warn("unknown routing strategy", "strategy", rule.Strategy, "src_tag", packet.srcTag)
+			continue
+		}
+
+		targets := policy.SelectTargets(packet, rule, func(tag string) bool {
+			return r.targetHealthy(tag, rule.HealthCheck)
+		})
+		if err := r.routeWithHealth(packet, targets, rule.HealthCheck); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// routeWithHealth delivers packet to each of destTags via deliverOne (not
+// Route, which would double-count packetsIn/bytesIn for this packet) and
+// feeds each delivery's real success/failure into recordHealth so the
+// outlier detector actually observes HandlePacket errors.
+func (r *Router) routeWithHealth(packet Packet, destTags []string, hc HealthCheckConfig) error {
+	var firstErr error
+	for _, tag := range destTags {
+		if tag == packet.srcTag {
+			continue
+		}
+		err := r.deliverOne(packet, tag)
+		r.recordHealth(tag, hc, err == nil)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// targetHealth is the sliding-window error-rate tracker for one target tag.
+type targetHealth struct {
+	mu           sync.Mutex
+	window       []bool
+	pos          int
+	filled       int
+	ejectedUntil time.Time
+}
+
+func (t *targetHealth) record(success bool, windowSize int) {
+	if windowSize <= 0 {
+		windowSize = 20
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.window) != windowSize {
+		t.window = make([]bool, windowSize)
+		t.pos, t.filled = 0, 0
+	}
+	t.window[t.pos%windowSize] = success
+	t.pos++
+	if t.filled < windowSize {
+		t.filled++
+	}
+}
+
+func (t *targetHealth) errorRate() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.filled == 0 {
+		return 0
+	}
+	fails := 0
+	for i := 0; i < t.filled; i++ {
+		if !t.window[i] {
+			fails++
+		}
+	}
+	return float64(fails) / float64(t.filled)
+}
+
+// eject marks the target as ejected for cooldown and clears its window, so
+// that once the cooldown passes it gets a clean half-open trial instead of
+// being immediately re-ejected by the stale error rate that caused it.
+func (t *targetHealth) eject(cooldown time.Duration) {
+	t.mu.Lock()
+	t.ejectedUntil = time.Now().Add(cooldown)
+	t.window = nil
+	t.filled, t.pos = 0, 0
+	t.mu.Unlock()
+}
+
+func (t *targetHealth) inCooldown() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return time.Now().Before(t.ejectedUntil)
+}
+
+func (r *Router) health(tag string) *targetHealth {
+	r.healthMu.Lock()
+	defer r.healthMu.Unlock()
+	h, ok := r.healthByTag[tag]
+	if !ok {
+		h = &targetHealth{}
+		r.healthByTag[tag] = h
+	}
+	return h
+}
+
+// recordHealth feeds a HandlePacket outcome into tag's sliding window and
+// ejects it for HealthCheck.CooldownSeconds once ErrorThreshold is
+// exceeded.
+func (r *Router) recordHealth(tag string, hc HealthCheckConfig, success bool) {
+	if hc.ErrorThreshold <= 0 {
+		return
+	}
+	h := r.health(tag)
+	h.record(success, hc.WindowSize)
+	if h.errorRate() >= hc.ErrorThreshold {
+		cooldown := time.Duration(hc.CooldownSeconds) * time.Second
+		if cooldown <= 0 {
+			cooldown = 10 * time.Second
+		}
+		h.eject(cooldown)
+	}
+}
+
+// targetHealthy reports whether tag is currently eligible for selection.
+func (r *Router) targetHealthy(tag string, hc HealthCheckConfig) bool {
+	if hc.ErrorThreshold <= 0 {
+		return true
+	}
+	return !r.health(tag).inCooldown()
+}
+
+func healthyTags(targets []WeightedTarget, isHealthy func(string) bool) []WeightedTarget {
+	healthy := make([]WeightedTarget, 0, len(targets))
+	for _, t := range targets {
+		if isHealthy(t.Tag) {
+			healthy = append(healthy, t)
+		}
+	}
+	if len(healthy) == 0 {
+		return targets // every target is ejected: fail open rather than black-hole traffic
+	}
+	return healthy
+}
+
+// broadcastPolicy reproduces the router's original behavior: every target
+// gets the packet, regardless of health.
+type broadcastPolicy struct{}
+
+func (broadcastPolicy) SelectTargets(_ Packet, rule RouteRule, _ func(string) bool) []string {
+	tags := make([]string, len(rule.Targets))
+	for i, t := range rule.Targets {
+		tags[i] = t.Tag
+	}
+	return tags
+}
+
+// roundRobinPolicy cycles through the healthy targets of a rule. Position
+// is tracked per distinct target set (joined tags) so unrelated rules, or
+// the same rule before/after a target-set change, don't share a cursor.
+type roundRobinPolicy struct {
+	mu   sync.Mutex
+	next map[string]int
+}
+
+func newRoundRobinPolicy() *roundRobinPolicy {
+	return &roundRobinPolicy{next: make(map[string]int)}
+}
+
+func (p *roundRobinPolicy) SelectTargets(_ Packet, rule RouteRule, isHealthy func(string) bool) []string {
+	targets := healthyTags(rule.Targets, isHealthy)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	key := targetSetKey(targets)
+	p.mu.Lock()
+	idx := p.next[key]
+	p.next[key] = idx + 1
+	p.mu.Unlock()
+
+	return []string{targets[idx%len(targets)].Tag}
+}
+
+func targetSetKey(targets []WeightedTarget) string {
+	tags := make([]string, len(targets))
+	for i, t := range targets {
+		tags[i] = t.Tag
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ",")
+}
+
+// weightedRandomPolicy picks one healthy target at random, proportional to
+// its configured Weight (targets with Weight <= 0 are treated as weight 1).
+type weightedRandomPolicy struct{}
+
+func (weightedRandomPolicy) SelectTargets(_ Packet, rule RouteRule, isHealthy func(string) bool) []string {
+	targets := healthyTags(rule.Targets, isHealthy)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, t := range targets {
+		total += weightOf(t)
+	}
+
+	pick := rand.Intn(total)
+	for _, t := range targets {
+		pick -= weightOf(t)
+		if pick < 0 {
+			return []string{t.Tag}
+		}
+	}
+	return []string{targets[len(targets)-1].Tag}
+}
+
+func weightOf(t WeightedTarget) int {
+	if t.Weight <= 0 {
+		return 1
+	}
+	return t.Weight
+}
+
+// hashPolicy gives every packet from the same flow the same destination
+// using rendezvous (highest random weight) hashing: for each target it
+// computes hash(flowKey, tag) and keeps the highest-scoring one. Unlike a
+// plain modulo hash, adding or removing a target only reshuffles the flows
+// that were mapped to that one target, not the whole keyspace.
+type hashPolicy struct{}
+
+func (hashPolicy) SelectTargets(packet Packet, rule RouteRule, isHealthy func(string) bool) []string {
+	targets := healthyTags(rule.Targets, isHealthy)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	key := flowKey(packet)
+	var best WeightedTarget
+	var bestScore uint64
+	for i, t := range targets {
+		score := rendezvousScore(key, t.Tag)
+		if i == 0 || score > bestScore {
+			best, bestScore = t, score
+		}
+	}
+	return []string{best.Tag}
+}
+
+// flowKey approximates a 5-tuple using what a Packet carries: the source
+// component's tag plus the originating client address, when known.
+func flowKey(packet Packet) string {
+	if packet.addr != nil {
+		return packet.srcTag + "|" + packet.addr.String()
+	}
+	return packet.srcTag
+}
+
+func rendezvousScore(key, tag string) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%s", key, tag)
+	return h.Sum64()
+}
+
+// failoverPolicy always prefers the first (primary) healthy target in
+// Targets order, falling back to the next one, and so on.
+type failoverPolicy struct{}
+
+func (failoverPolicy) SelectTargets(_ Packet, rule RouteRule, isHealthy func(string) bool) []string {
+	for _, t := range rule.Targets {
+		if isHealthy(t.Tag) {
+			return []string{t.Tag}
+		}
+	}
+	if len(rule.Targets) == 0 {
+		return nil
+	}
+	return []string{rule.Targets[0].Tag} // everything ejected: fail open to the primary
+}
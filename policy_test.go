@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/tao08141/double_udp/logging"
+	"go.opentelemetry.io/otel"
+)
+
+// stubComponent is a minimal Component used to exercise Dispatch/Route
+// without any real networking.
+type stubComponent struct {
+	tag   string
+	fail  bool
+	calls int
+}
+
+func (s *stubComponent) GetTag() string { return s.tag }
+func (s *stubComponent) Start() error   { return nil }
+func (s *stubComponent) Stop() error    { return nil }
+func (s *stubComponent) HandlePacket(Packet) error {
+	s.calls++
+	if s.fail {
+		return errors.New("stub delivery failure")
+	}
+	return nil
+}
+
+// newTestRouter builds a Router with a real policy/health registry but no
+// network side effects, suitable for exercising Policy implementations
+// directly.
+func newTestRouter() *Router {
+	logger := logging.New(logging.FormatText, logging.LevelError, io.Discard)
+	r := NewRouter(Config{BufferSize: 1500}, logger, otel.Tracer("test"))
+	r.RegisterBuiltinPolicies()
+	return r
+}
+
+func alwaysHealthy(string) bool { return true }
+
+func fakeAddr(port int) net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+}
+
+// TestHashPolicyStabilityAcrossTargetSetChanges verifies the defining
+// property of rendezvous hashing: adding or removing one target only
+// remaps the flows whose top-scored target changed, not the whole
+// keyspace.
+func TestHashPolicyStabilityAcrossTargetSetChanges(t *testing.T) {
+	policy := hashPolicy{}
+	before := []WeightedTarget{{Tag: "a"}, {Tag: "b"}, {Tag: "c"}, {Tag: "d"}}
+	after := append(append([]WeightedTarget{}, before...), WeightedTarget{Tag: "e"})
+
+	const flows = 2000
+	remapped := 0
+	for i := 0; i < flows; i++ {
+		packet := Packet{srcTag: "listen", addr: fakeAddr(i)}
+
+		beforeTags := policy.SelectTargets(packet, RouteRule{Targets: before}, alwaysHealthy)
+		afterTags := policy.SelectTargets(packet, RouteRule{Targets: after}, alwaysHealthy)
+
+		if len(beforeTags) != 1 || len(afterTags) != 1 {
+			t.Fatalf("expected exactly one target, got %v / %v", beforeTags, afterTags)
+		}
+		if beforeTags[0] != afterTags[0] {
+			remapped++
+		}
+	}
+
+	// With 4 targets growing to 5, an ideal hash only remaps flows whose
+	// winner becomes the new target "e" (~1/5 of the keyspace). Allow
+	// generous slack for hash skew but fail if remapping looks anywhere
+	// near a full reshuffle (which a plain modulo hash would cause).
+	if got, want := float64(remapped)/flows, 0.35; got > want {
+		t.Fatalf("too many flows remapped after adding a target: %.2f%% (want <= %.0f%%)", got*100, want*100)
+	}
+}
+
+// TestFailoverConvergenceTime verifies that once the primary target is
+// ejected by the health tracker, failoverPolicy converges onto the backup
+// within a single health-check cooldown window rather than continuing to
+// flap back to the unhealthy primary.
+func TestFailoverConvergenceTime(t *testing.T) {
+	r := newTestRouter()
+	hc := HealthCheckConfig{WindowSize: 4, ErrorThreshold: 0.5, CooldownSeconds: 0}
+	// CooldownSeconds of 0 falls back to the 10s default inside
+	// recordHealth; use a manual eject instead so the test doesn't need to
+	// sleep real wall-clock time.
+	rule := RouteRule{
+		Strategy: "failover",
+		Targets:  []WeightedTarget{{Tag: "primary"}, {Tag: "backup"}},
+	}
+
+	isHealthy := func(tag string) bool { return r.targetHealthy(tag, hc) }
+
+	targets := failoverPolicy{}.SelectTargets(Packet{}, rule, isHealthy)
+	if len(targets) != 1 || targets[0] != "primary" {
+		t.Fatalf("expected primary while healthy, got %v", targets)
+	}
+
+	// Record enough failures to cross ErrorThreshold and eject "primary".
+	for i := 0; i < hc.WindowSize; i++ {
+		r.recordHealth("primary", hc, false)
+	}
+	if !r.health("primary").inCooldown() {
+		t.Fatal("expected primary to be ejected after crossing the error threshold")
+	}
+
+	targets = failoverPolicy{}.SelectTargets(Packet{}, rule, isHealthy)
+	if len(targets) != 1 || targets[0] != "backup" {
+		t.Fatalf("expected immediate failover to backup, got %v", targets)
+	}
+
+	// Once cooldown elapses, the primary is eligible again.
+	r.health("primary").ejectedUntil = time.Now().Add(-time.Second)
+	targets = failoverPolicy{}.SelectTargets(Packet{}, rule, isHealthy)
+	if len(targets) != 1 || targets[0] != "primary" {
+		t.Fatalf("expected primary back after cooldown elapsed, got %v", targets)
+	}
+}
+
+// TestDispatchEjectsFailingTarget is an integration-level test that goes
+// through Router.Dispatch (not recordHealth directly) to verify that a
+// target whose HandlePacket always errors actually gets ejected once its
+// error rate crosses ErrorThreshold.
+func TestDispatchEjectsFailingTarget(t *testing.T) {
+	r := newTestRouter()
+	bad := &stubComponent{tag: "bad", fail: true}
+	if err := r.Register(bad); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	cfg := ComponentConfig{
+		Tag: "src",
+		Route: []RouteRule{{
+			Strategy: "broadcast",
+			Targets:  []WeightedTarget{{Tag: "bad"}},
+			HealthCheck: HealthCheckConfig{
+				WindowSize:      4,
+				ErrorThreshold:  0.5,
+				CooldownSeconds: 60,
+			},
+		}},
+	}
+
+	for i := 0; i < 20; i++ {
+		_ = r.Dispatch(Packet{srcTag: "src", data: []byte("x")}, cfg)
+	}
+
+	if bad.calls == 0 {
+		t.Fatal("expected HandlePacket to have been called at least once")
+	}
+	if r.targetHealthy("bad", cfg.Route[0].HealthCheck) {
+		t.Fatal("expected target to be ejected after repeated HandlePacket failures")
+	}
+}
+
+// TestDispatchCountsPacketOnceAcrossFanOut verifies that fanning a single
+// packet out to multiple targets via a broadcast rule increments
+// packets_in_total/bytes_in_total once, not once per target.
+func TestDispatchCountsPacketOnceAcrossFanOut(t *testing.T) {
+	r := newTestRouter()
+	for _, tag := range []string{"a", "b", "c"} {
+		if err := r.Register(&stubComponent{tag: tag}); err != nil {
+			t.Fatalf("Register(%s): %v", tag, err)
+		}
+	}
+
+	cfg := ComponentConfig{
+		Tag: "src",
+		Route: []RouteRule{{
+			Strategy: "broadcast",
+			Targets:  []WeightedTarget{{Tag: "a"}, {Tag: "b"}, {Tag: "c"}},
+		}},
+	}
+
+	if err := r.Dispatch(Packet{srcTag: "src", data: []byte("hello")}, cfg); err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+
+	if got := testutil.ToFloat64(r.metrics.packetsIn.WithLabelValues("src")); got != 1 {
+		t.Fatalf("packets_in_total = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(r.metrics.bytesIn.WithLabelValues("src")); got != 5 {
+		t.Fatalf("bytes_in_total = %v, want 5", got)
+	}
+}
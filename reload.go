@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// ReloadOutcome describes what Router.Reload did with one component tag.
+type ReloadOutcome string
+
+const (
+	OutcomeAdded     ReloadOutcome = "added"
+	OutcomeRemoved   ReloadOutcome = "removed"
+	OutcomeUpdated   ReloadOutcome = "updated"
+	OutcomeUnchanged ReloadOutcome = "unchanged"
+	OutcomeFailed    ReloadOutcome = "failed"
+)
+
+// ReloadReport is the per-tag result of a Router.Reload call.
+type ReloadReport struct {
+	Results map[string]ReloadOutcome `json:"results"`
+	Errors  map[string]string        `json:"errors,omitempty"`
+}
+
+// Reconfigurable is implemented by components that can apply certain
+// ComponentConfig changes (forwarder list, detour list, queue size,
+// reconnect interval) without being restarted. Components that don't
+// implement it are always restarted by Reload when their config changes.
+type Reconfigurable interface {
+	Reconfigure(cfg ComponentConfig)
+}
+
+// SetConfigPath records where config.json lives so ReloadFromDisk knows
+// what to re-read.
+func (r *Router) SetConfigPath(path string) {
+	r.configPath = path
+}
+
+// ReloadFromDisk re-reads the file set by SetConfigPath and applies it via
+// Reload. It is what both the SIGHUP handler and the adminapi /reload
+// endpoint call.
+func (r *Router) ReloadFromDisk() (ReloadReport, error) {
+	data, err := os.ReadFile(r.configPath)
+	if err != nil {
+		return ReloadReport{}, fmt.Errorf("failed to read config: %w", err)
+	}
+	var newConfig Config
+	if err := json.Unmarshal(data, &newConfig); err != nil {
+		return ReloadReport{}, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return r.Reload(newConfig), nil
+}
+
+// Reload diffs newConfig against the currently running components, stopping
+// and removing components whose tag disappeared, starting components for
+// newly added tags, applying in-place updates where safe (see
+// Reconfigurable) and otherwise performing a stop/start restart. No packet
+// in flight is dropped for tags that are untouched or safely reconfigured.
+func (r *Router) Reload(newConfig Config) ReloadReport {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	report := ReloadReport{
+		Results: make(map[string]ReloadOutcome),
+		Errors:  make(map[string]string),
+	}
+
+	newConfigs := make(map[string]ComponentConfig, len(newConfig.Services))
+	for _, cfg := range newConfig.Services {
+		if cfg.BufferSize <= 0 {
+			cfg.BufferSize = newConfig.BufferSize
+		}
+		newConfigs[cfg.Tag] = cfg
+	}
+
+	r.mu.Lock()
+	oldConfigs := make(map[string]ComponentConfig, len(r.configs))
+	for tag, cfg := range r.configs {
+		oldConfigs[tag] = cfg
+	}
+	r.mu.Unlock()
+
+	for tag := range oldConfigs {
+		if _, ok := newConfigs[tag]; ok {
+			continue
+		}
+		if err := r.removeComponent(tag); err != nil {
+			report.Results[tag] = OutcomeFailed
+			report.Errors[tag] = err.Error()
+			continue
+		}
+		report.Results[tag] = OutcomeRemoved
+	}
+
+	for tag, cfg := range newConfigs {
+		old, existed := oldConfigs[tag]
+		if !existed {
+			if err := r.addComponent(cfg); err != nil {
+				report.Results[tag] = OutcomeFailed
+				report.Errors[tag] = err.Error()
+				continue
+			}
+			report.Results[tag] = OutcomeAdded
+			continue
+		}
+
+		if reflect.DeepEqual(old, cfg) {
+			report.Results[tag] = OutcomeUnchanged
+			continue
+		}
+
+		var err error
+		if onlyRoutingChanged(old, cfg) {
+			err = r.updateComponentInPlace(tag, cfg)
+		} else {
+			err = r.restartComponent(tag, cfg)
+		}
+		if err != nil {
+			report.Results[tag] = OutcomeFailed
+			report.Errors[tag] = err.Error()
+			continue
+		}
+		report.Results[tag] = OutcomeUpdated
+	}
+
+	return report
+}
+
+// onlyRoutingChanged reports whether old and cfg differ only in the fields
+// Reconfigurable implementations know how to apply live: forwarder list,
+// detour list, queue size and reconnect interval. Anything else (listen
+// address, buffer size, type, ...) needs a restart.
+func onlyRoutingChanged(old, cfg ComponentConfig) bool {
+	merged := old
+	merged.Forwarders = cfg.Forwarders
+	merged.Detour = cfg.Detour
+	merged.QueueSize = cfg.QueueSize
+	merged.ReconnectInterval = cfg.ReconnectInterval
+	return reflect.DeepEqual(merged, cfg)
+}
+
+func (r *Router) addComponent(cfg ComponentConfig) error {
+	c, err := r.NewComponent(cfg)
+	if err != nil {
+		return err
+	}
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return r.RegisterWithConfig(cfg, c)
+}
+
+func (r *Router) removeComponent(tag string) error {
+	r.mu.Lock()
+	c, ok := r.components[tag]
+	delete(r.components, tag)
+	delete(r.configs, tag)
+	r.mu.Unlock()
+	if !ok || c == nil {
+		return nil
+	}
+	return c.Stop()
+}
+
+func (r *Router) updateComponentInPlace(tag string, cfg ComponentConfig) error {
+	r.mu.RLock()
+	c, ok := r.components[tag]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("component %s not found", tag)
+	}
+
+	rc, ok := c.(Reconfigurable)
+	if !ok {
+		return r.restartComponent(tag, cfg)
+	}
+	rc.Reconfigure(cfg)
+
+	r.mu.Lock()
+	r.configs[tag] = cfg
+	r.mu.Unlock()
+	return nil
+}
+
+// restartComponent performs a gracefully-drained restart: the old component
+// is stopped (letting it finish in-flight work) before the replacement is
+// created and started under the same tag.
+func (r *Router) restartComponent(tag string, cfg ComponentConfig) error {
+	r.mu.RLock()
+	old, ok := r.components[tag]
+	r.mu.RUnlock()
+	if ok && old != nil {
+		if err := old.Stop(); err != nil {
+			r.logger.Warn("error stopping component for restart", "component", tag, "error", err)
+		}
+	}
+
+	c, err := r.NewComponent(cfg)
+	if err != nil {
+		return err
+	}
+	if err := c.Start(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.components[tag] = c
+	r.configs[tag] = cfg
+	r.mu.Unlock()
+	return nil
+}
+
+// DrainComponent stops a single component without touching the rest of the
+// pipeline, letting an operator pause one forwarder. The component stays
+// registered under its tag; a later Reload with the same config will
+// restart it since Reload only restarts tags whose config actually changed,
+// so draining is currently a one-way operation reversed by restarting the
+// whole process or changing that tag's config to force a restart.
+func (r *Router) DrainComponent(tag string) error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	r.mu.RLock()
+	c, ok := r.components[tag]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no such component: %s", tag)
+	}
+	return c.Stop()
+}
+
+// ComponentInfo is the summary returned by ListComponents.
+type ComponentInfo struct {
+	Tag  string `json:"tag"`
+	Type string `json:"type"`
+}
+
+// ListComponents returns one entry per registered component.
+func (r *Router) ListComponents() []ComponentInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	infos := make([]ComponentInfo, 0, len(r.configs))
+	for tag, cfg := range r.configs {
+		infos = append(infos, ComponentInfo{Tag: tag, Type: cfg.Type})
+	}
+	return infos
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tao08141/double_udp/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Router manages all components and routes packets between them
+type Router struct {
+	components map[string]Component
+	// configs holds the ComponentConfig each component was created from, so
+	// Reload can diff the running set against a new Config.
+	configs    map[string]ComponentConfig
+	mu         sync.RWMutex
+	bufferPool sync.Pool
+
+	typesMu sync.RWMutex
+	types   map[string]ComponentFactory
+
+	policiesMu sync.RWMutex
+	policies   map[string]Policy
+
+	healthMu    sync.Mutex
+	healthByTag map[string]*targetHealth
+
+	logger        *logging.Logger
+	tracer        trace.Tracer
+	metrics       *metrics
+	observability ObservabilityConfig
+
+	// configPath is the file ReloadFromDisk re-reads; set via SetConfigPath.
+	configPath string
+
+	// reloadMu serializes Reload and DrainComponent against each other and
+	// against themselves, so a SIGHUP racing an adminapi /reload (or two
+	// concurrent /reload calls) can't both stop the same component.
+	reloadMu sync.Mutex
+}
+
+// NewRouter creates a new router. logger is used for Router's own log lines
+// (Route warnings, Start/StopAll) and is also what Named sub-loggers for
+// each component are derived from; see Logger. tracer is used to link spans
+// across listen->router->forward hops; pass the no-op tracer returned by
+// newTracer when tracing is disabled.
+func NewRouter(config Config, logger *logging.Logger, tracer trace.Tracer) *Router {
+	return &Router{
+		components:    make(map[string]Component),
+		configs:       make(map[string]ComponentConfig),
+		types:         make(map[string]ComponentFactory),
+		policies:      make(map[string]Policy),
+		healthByTag:   make(map[string]*targetHealth),
+		logger:        logger,
+		tracer:        tracer,
+		metrics:       newMetrics(),
+		observability: config.Observability,
+		bufferPool: sync.Pool{
+			New: func() any {
+				buf := make([]byte, config.BufferSize)
+				return &buf // Return pointer to slice
+			},
+		},
+	}
+}
+
+// Logger returns the router's logger, so components can derive a named
+// sub-logger for themselves (e.g. router.Logger().Named(cfg.Tag)).
+func (r *Router) Logger() *logging.Logger {
+	return r.logger
+}
+
+// RegisterComponentType makes a component type available to NewComponent
+// under name. Built-in types are registered by RegisterBuiltin; plugins and
+// out-of-process components register additional types at startup via
+// LoadPlugins. Re-registering an existing name overwrites it, so plugins
+// may override a builtin if they need to.
+func (r *Router) RegisterComponentType(name string, factory ComponentFactory) {
+	r.typesMu.Lock()
+	defer r.typesMu.Unlock()
+	r.types[name] = factory
+}
+
+// RegisterBuiltin pre-registers the component types this module ships with.
+func (r *Router) RegisterBuiltin() {
+	r.RegisterComponentType("listen", func(cfg ComponentConfig, router *Router) (Component, error) {
+		return NewListenComponent(cfg, router), nil
+	})
+	r.RegisterComponentType("forward", func(cfg ComponentConfig, router *Router) (Component, error) {
+		return NewForwardComponent(cfg, router), nil
+	})
+	r.RegisterComponentType("external", NewExternalComponent)
+	r.RegisterComponentType("httpadmin", NewHTTPAdminComponent)
+	r.RegisterComponentType("adminapi", NewAdminAPIComponent)
+	r.RegisterBuiltinPolicies()
+}
+
+// NewComponent builds a component of cfg.Type using the factory registered
+// under that name. Callers (main, Reload) no longer need to know which
+// types are built in versus loaded from a plugin or out-of-process helper.
+func (r *Router) NewComponent(cfg ComponentConfig) (Component, error) {
+	r.typesMu.RLock()
+	factory, ok := r.types[cfg.Type]
+	r.typesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown component type: %s", cfg.Type)
+	}
+	return factory(cfg, r)
+}
+
+// MetricsRegistry returns the Prometheus registry backing this router's
+// metrics, so an httpadmin component can serve it over /metrics.
+func (r *Router) MetricsRegistry() *prometheus.Registry {
+	return r.metrics.registry
+}
+
+// DefaultPrometheusAddr returns the Observability.PrometheusAddr the Router
+// was configured with, for an httpadmin component that wasn't given its own
+// listen_addr to fall back to.
+func (r *Router) DefaultPrometheusAddr() string {
+	return r.observability.PrometheusAddr
+}
+
+// NewPacket builds a Packet originating from srcTag and starts the "recv"
+// span that follows it through the rest of the pipeline. Components should
+// call this instead of constructing a Packet literal so every hop is
+// traceable.
+func (r *Router) NewPacket(srcTag string, data []byte, addr net.Addr) Packet {
+	ctx, span := r.tracer.Start(context.Background(), "recv", trace.WithAttributes(attribute.String("src_tag", srcTag)))
+	span.End()
+	return Packet{srcTag: srcTag, data: data, addr: addr, ctx: ctx}
+}
+
+// GetBuffer retrieves a buffer from the pool
+func (r *Router) GetBuffer() []byte {
+	r.metrics.bufferGets.Inc()
+	return *(r.bufferPool.Get().(*[]byte))
+}
+
+// PutBuffer returns a buffer to the pool
+func (r *Router) PutBuffer(buf []byte) {
+	r.bufferPool.Put(&buf)
+}
+
+// Register adds a component to the router
+func (r *Router) Register(c Component) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tag := c.GetTag()
+	if tag == "" {
+		return fmt.Errorf("component has empty tag")
+	}
+
+	if _, exists := r.components[tag]; exists {
+		return fmt.Errorf("component with tag %s already registered", tag)
+	}
+
+	r.components[tag] = c
+	return nil
+}
+
+// RegisterWithConfig registers c like Register, additionally remembering
+// the ComponentConfig it was created from so Reload can later diff against
+// it. main and Reload should use this instead of the plain Register.
+func (r *Router) RegisterWithConfig(cfg ComponentConfig, c Component) error {
+	if err := r.Register(c); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.configs[cfg.Tag] = cfg
+	r.mu.Unlock()
+	return nil
+}
+
+// ComponentStats is a snapshot of one component's basic runtime info,
+// returned by GetStats.
+type ComponentStats struct {
+	Tag  string `json:"tag"`
+	Type string `json:"type"`
+}
+
+// GetStats returns a snapshot of every registered component. Detailed
+// traffic counters are available per-component from the Prometheus
+// registry (see MetricsRegistry); GetStats is the lightweight view used by
+// the admin API to answer "what is currently running".
+func (r *Router) GetStats() map[string]ComponentStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make(map[string]ComponentStats, len(r.configs))
+	for tag, cfg := range r.configs {
+		stats[tag] = ComponentStats{Tag: tag, Type: cfg.Type}
+	}
+	return stats
+}
+
+// GetComponent returns a component by its tag
+func (r *Router) GetComponent(tag string) (Component, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, exists := r.components[tag]
+	return c, exists
+}
+
+// Route sends a packet to components specified by their tags. The packet is
+// counted once against packetsIn/bytesIn regardless of how many destinations
+// it fans out to; per-destination outcomes are handled by deliverOne.
+func (r *Router) Route(packet Packet, destTags []string) error {
+	r.metrics.packetsIn.WithLabelValues(packet.srcTag).Inc()
+	r.metrics.bytesIn.WithLabelValues(packet.srcTag).Add(float64(len(packet.data)))
+
+	var firstErr error
+	for _, tag := range destTags {
+		if tag == packet.srcTag {
+			continue // Don't route back to source
+		}
+		if err := r.deliverOne(packet, tag); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// deliverOne hands packet to the single component registered under tag,
+// recording the delivery outcome in metrics/tracing and returning
+// HandlePacket's error (if any) so callers such as routeWithHealth can act
+// on a per-target success/failure signal instead of it being swallowed.
+func (r *Router) deliverOne(packet Packet, tag string) error {
+	c, exists := r.GetComponent(tag)
+	if !exists {
+		r.metrics.routeErrors.WithLabelValues(tag).Inc()
+		r.logger.Warn("trying to route to non-existing component", "src_tag", packet.srcTag, "dst_tag", tag)
+		return fmt.Errorf("component %s not registered", tag)
+	}
+
+	r.logger.Trace("route", "routing packet", "src_tag", packet.srcTag, "dst_tag", tag, "bytes", len(packet.data))
+
+	ctx := packet.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := r.tracer.Start(ctx, "route", trace.WithAttributes(
+		attribute.String("src_tag", packet.srcTag),
+		attribute.String("dst_tag", tag),
+	))
+	start := time.Now()
+	err := c.HandlePacket(packet)
+	span.End()
+
+	if err != nil {
+		r.metrics.routeErrors.WithLabelValues(tag).Inc()
+		r.logger.Error("error routing packet", "src_tag", packet.srcTag, "dst_tag", tag, "error", err)
+		return err
+	}
+	r.metrics.packetsOut.WithLabelValues(tag).Inc()
+	r.metrics.bytesOut.WithLabelValues(tag).Add(float64(len(packet.data)))
+	r.metrics.forwardLatency.WithLabelValues(tag).Observe(time.Since(start).Seconds())
+	return nil
+}
+
+// StartAll starts all registered components
+func (r *Router) StartAll() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for tag, component := range r.components {
+		r.logger.Info("starting component", "component", tag)
+		if err := component.Start(); err != nil {
+			return fmt.Errorf("failed to start component %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// StopAll stops all registered components
+func (r *Router) StopAll() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for tag, component := range r.components {
+		r.logger.Info("stopping component", "component", tag)
+		if err := component.Stop(); err != nil {
+			r.logger.Error("error stopping component", "component", tag, "error", err)
+		}
+	}
+}
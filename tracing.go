@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracer builds a trace.Tracer for this module's spans. When cfg has no
+// OTLPEndpoint it returns the no-op tracer from the global otel provider, so
+// instrumentation call sites don't need to special-case "tracing disabled".
+func newTracer(ctx context.Context, cfg ObservabilityConfig) (trace.Tracer, func(context.Context) error, error) {
+	if cfg.OTLPEndpoint == "" {
+		return otel.Tracer("double_udp"), func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint), otlptracegrpc.WithInsecure())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	ratio := cfg.SamplerRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+
+	res, err := resource.New(ctx, resource.WithAttributes(semconv.ServiceName("double_udp")))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp.Tracer("double_udp"), tp.Shutdown, nil
+}